@@ -0,0 +1,224 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+const (
+	deliveryQueueSize   = 32
+	maxDeliveryAttempts = 8
+	// unhealthyThreshold is the number of consecutive delivery failures
+	// after which a subscription is marked unhealthy rather than retried
+	// forever.
+	unhealthyThreshold  = 5
+	deliveryBackoffBase = 2 * time.Second
+	deliveryBackoffMax  = 10 * time.Minute
+)
+
+// subscriberKey identifies the per-subscription delivery queue.
+type subscriberKey struct {
+	Topic    string
+	Callback string
+}
+
+type deliveryJob struct {
+	sub     *Subscription
+	content *TopicContent
+}
+
+// subscriptionQueue is a bounded mailbox of notifications for one
+// subscription, drained by a single dedicated worker goroutine so that a
+// slow or failing subscriber never blocks delivery to anyone else.
+type subscriptionQueue struct {
+	jobs chan *deliveryJob
+	done chan struct{}
+}
+
+// enqueueDelivery hands a fetched topic notification to the subscription's
+// delivery queue, starting its worker on first use. If the queue is full the
+// notification is dropped and logged rather than blocking the publisher.
+//
+// sub is carried on the job itself, not captured once at queue-creation
+// time: each publish fetches the subscription afresh from the repository
+// (so a renewed lease or rotated secret is reflected), and the worker must
+// use that latest copy rather than whatever was current when its queue was
+// first created.
+func (h *Hub) enqueueDelivery(sub *Subscription, content *TopicContent) {
+	key := subscriberKey{Topic: sub.Topic, Callback: sub.CallbackURL.String()}
+
+	h.deliveryMu.Lock()
+	q, ok := h.deliveryQueues[key]
+	if !ok {
+		q = &subscriptionQueue{
+			jobs: make(chan *deliveryJob, deliveryQueueSize),
+			done: make(chan struct{}),
+		}
+		h.deliveryQueues[key] = q
+		go h.deliveryWorker(key, q)
+	}
+	h.deliveryMu.Unlock()
+
+	select {
+	case q.jobs <- &deliveryJob{sub: sub, content: content}:
+	default:
+		h.logger.Error("delivery queue full, dropping notification", "callback", key.Callback, "topic", key.Topic)
+	}
+}
+
+// stopDeliveryQueue tears down a subscription's delivery worker, e.g. after
+// unsubscribe or a 410 Gone response.
+func (h *Hub) stopDeliveryQueue(key subscriberKey) {
+	h.deliveryMu.Lock()
+	q, ok := h.deliveryQueues[key]
+	if ok {
+		delete(h.deliveryQueues, key)
+	}
+	h.deliveryMu.Unlock()
+
+	if ok {
+		close(q.done)
+	}
+}
+
+func (h *Hub) deliveryWorker(key subscriberKey, q *subscriptionQueue) {
+	for {
+		select {
+		case <-q.done:
+			return
+		case job := <-q.jobs:
+			h.runDelivery(key, job.sub, job.content)
+		}
+	}
+}
+
+// runDelivery POSTs the notification to the subscriber, retrying with
+// exponential backoff and jitter on failure. A 410 Gone auto-unsubscribes the
+// callback; repeated failures beyond unhealthyThreshold mark the subscription
+// unhealthy and drop it once its lease next expires.
+func (h *Hub) runDelivery(key subscriberKey, sub *Subscription, content *TopicContent) {
+	for attempt := 1; attempt <= maxDeliveryAttempts; attempt++ {
+		sub.DeliveryAttempts++
+
+		start := time.Now()
+		status, err := h.attemptDelivery(sub, content)
+		h.metrics.observeDelivery(status, time.Since(start), len(content.Body))
+
+		if err == nil && status >= 200 && status <= 299 {
+			sub.DeliveryAttempts = 0
+			sub.ConsecutiveFailures = 0
+			sub.NextDeliveryAttempt = time.Time{}
+			h.persistDeliveryState(key, sub)
+			return
+		}
+
+		if status == http.StatusGone {
+			h.logger.Info("callback returned 410 Gone, auto-unsubscribing", "callback", key.Callback, "topic", key.Topic)
+			h.unsubscribeCallback(key)
+			return
+		}
+
+		h.logger.Error("delivery attempt failed", "callback", key.Callback, "topic", key.Topic, "attempt", attempt, "status", status, "err", err)
+
+		if attempt == maxDeliveryAttempts {
+			sub.ConsecutiveFailures++
+			h.persistDeliveryState(key, sub)
+			if sub.ConsecutiveFailures >= unhealthyThreshold {
+				h.markUnhealthy(key, sub)
+			}
+			return
+		}
+
+		wait := deliveryBackoff(attempt)
+		sub.NextDeliveryAttempt = time.Now().Add(wait)
+		h.persistDeliveryState(key, sub)
+		time.Sleep(wait)
+	}
+}
+
+// persistDeliveryState writes the subscription's delivery attempt counters
+// back to the repository, so a restart doesn't reset a subscriber's failure
+// history to zero.
+func (h *Hub) persistDeliveryState(key subscriberKey, sub *Subscription) {
+	err := h.subs.UpdateDeliveryState(context.Background(), key.Topic, key.Callback, sub.DeliveryAttempts, sub.ConsecutiveFailures, sub.Unhealthy)
+	if err != nil {
+		h.logger.Error("failed to persist delivery state", "topic", key.Topic, "callback", key.Callback, "err", err)
+	}
+}
+
+func deliveryBackoff(attempt int) time.Duration {
+	d := deliveryBackoffBase * time.Duration(1<<uint(attempt))
+	if d > deliveryBackoffMax {
+		d = deliveryBackoffMax
+	}
+	jitter := time.Duration(rand.Int63n(int64(d)/2 + 1))
+	return d/2 + jitter
+}
+
+// markUnhealthy flags a subscription as unhealthy and schedules its removal
+// once the current lease expires, instead of tearing it down mid-lease.
+func (h *Hub) markUnhealthy(key subscriberKey, sub *Subscription) {
+	h.logger.Info("subscription marked unhealthy after repeated delivery failures", "callback", key.Callback, "topic", key.Topic)
+	sub.SetUnhealthy(true)
+
+	delay := time.Until(sub.LeaseDeadlineAt())
+	if delay < 0 {
+		delay = 0
+	}
+	time.AfterFunc(delay, func() {
+		if sub.IsUnhealthy() {
+			h.unsubscribeCallback(key)
+		}
+	})
+}
+
+// unsubscribeCallback removes a subscription from the repository and tears
+// down its delivery queue.
+func (h *Hub) unsubscribeCallback(key subscriberKey) {
+	if err := h.subs.Remove(context.Background(), key.Topic, key.Callback); err != nil {
+		h.logger.Error("failed to remove subscription", "topic", key.Topic, "callback", key.Callback, "err", err)
+	}
+	h.stopDeliveryQueue(key)
+}
+
+// attemptDelivery performs a single POST attempt to the subscriber, draining
+// and closing the response body so connections are never leaked.
+func (h *Hub) attemptDelivery(sub *Subscription, content *TopicContent) (int, error) {
+	callbackStr := sub.CallbackURL.String()
+
+	req, err := http.NewRequest(http.MethodPost, callbackStr, bytes.NewReader(content.Body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", content.ContentType)
+
+	if len(sub.secret) > 0 {
+		mac := hmac.New(sha256.New, sub.secret)
+		if _, err := mac.Write(content.Body); err != nil {
+			return 0, err
+		}
+		sig := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+		req.Header.Add("X-Hub-Signature", sig)
+	}
+
+	res, err := h.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+	io.Copy(io.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return res.StatusCode, errors.New("callback returned " + res.Status)
+	}
+
+	return res.StatusCode, nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestDeliveryBackoffWithinBounds(t *testing.T) {
+	for attempt := 1; attempt <= 10; attempt++ {
+		d := deliveryBackoff(attempt)
+		if d <= 0 {
+			t.Errorf("deliveryBackoff(%d) = %v, want > 0", attempt, d)
+		}
+		if d > deliveryBackoffMax {
+			t.Errorf("deliveryBackoff(%d) = %v, want <= %v", attempt, d, deliveryBackoffMax)
+		}
+	}
+}
+
+func newTestSubscription(t *testing.T, callbackURL, topic string) *Subscription {
+	t.Helper()
+	u, err := url.Parse(callbackURL)
+	if err != nil {
+		t.Fatalf("parsing callback url: %v", err)
+	}
+	return &Subscription{
+		CallbackURL:   u,
+		Topic:         topic,
+		LeaseDeadline: time.Now().Add(time.Hour),
+		Lease:         time.Hour,
+	}
+}
+
+func TestRunDeliverySuccess(t *testing.T) {
+	received := make(chan []byte, 1)
+	subscriber := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- body
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer subscriber.Close()
+
+	h := NewHub(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	sub := newTestSubscription(t, subscriber.URL, "https://example.com/feed")
+	sub.DeliveryAttempts = 3
+	sub.ConsecutiveFailures = 2
+	if err := h.subs.Add(context.Background(), sub); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	key := subscriberKey{Topic: sub.Topic, Callback: sub.CallbackURL.String()}
+	content := &TopicContent{ContentType: "text/plain", Body: []byte("hello")}
+
+	h.runDelivery(key, sub, content)
+
+	select {
+	case body := <-received:
+		if string(body) != "hello" {
+			t.Errorf("subscriber received body = %q, want hello", body)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("subscriber never received the notification")
+	}
+
+	if sub.DeliveryAttempts != 0 || sub.ConsecutiveFailures != 0 {
+		t.Errorf("after success, DeliveryAttempts=%d ConsecutiveFailures=%d, want 0, 0", sub.DeliveryAttempts, sub.ConsecutiveFailures)
+	}
+
+	stored, err := h.subs.ListByTopic(context.Background(), sub.Topic)
+	if err != nil {
+		t.Fatalf("ListByTopic: %v", err)
+	}
+	if len(stored) != 1 || stored[0].ConsecutiveFailures != 0 {
+		t.Error("successful delivery was not persisted back to the repository")
+	}
+}
+
+func TestRunDeliveryGoneUnsubscribes(t *testing.T) {
+	subscriber := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusGone)
+	}))
+	defer subscriber.Close()
+
+	h := NewHub(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	sub := newTestSubscription(t, subscriber.URL, "https://example.com/feed")
+	if err := h.subs.Add(context.Background(), sub); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	key := subscriberKey{Topic: sub.Topic, Callback: sub.CallbackURL.String()}
+	content := &TopicContent{ContentType: "text/plain", Body: []byte("hello")}
+
+	h.runDelivery(key, sub, content)
+
+	remaining, err := h.subs.ListByTopic(context.Background(), sub.Topic)
+	if err != nil {
+		t.Fatalf("ListByTopic: %v", err)
+	}
+	if len(remaining) != 0 {
+		t.Errorf("subscription count after 410 Gone = %d, want 0 (auto-unsubscribed)", len(remaining))
+	}
+}
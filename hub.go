@@ -1,14 +1,9 @@
 package main
 
 import (
-	"bytes"
 	"context"
-	"crypto/hmac"
 	"crypto/rand"
-	"crypto/sha256"
 	"encoding/base64"
-	"encoding/hex"
-	"encoding/json"
 	"fmt"
 	"io"
 	"log/slog"
@@ -20,32 +15,114 @@ import (
 )
 
 type Hub struct {
-	logger        *slog.Logger
-	mux           *http.ServeMux
-	subscriptions map[string]*Subscription
-	rwMu          sync.RWMutex
-	client        *http.Client
+	logger *slog.Logger
+	mux    *http.ServeMux
+	subs   SubscriptionRepository
+	client *http.Client
+	topics TopicRepository
+
+	verifyWorkers int
+	verifyQueue   chan *pendingVerification
+	pendingMu     sync.Mutex
+	pending       map[verificationKey]*pendingVerification
+
+	deliveryMu     sync.Mutex
+	deliveryQueues map[subscriberKey]*subscriptionQueue
+
+	leaseReapInterval time.Duration
+	minLease          time.Duration
+	maxLease          time.Duration
+	onExpiry          func(*Subscription)
+
+	metrics *metrics
+
+	selfURL         string
+	strictDiscovery bool
+}
+
+// WithSelfURL sets the hub's own canonical URL, used to match against
+// rel="hub" Link headers advertised by topic resources.
+func WithSelfURL(url string) Option {
+	return func(h *Hub) {
+		h.selfURL = url
+	}
+}
+
+// WithStrictDiscovery rejects subscriptions and publish fetches for topics
+// that don't advertise this hub via a rel="hub" Link header. Requires
+// WithSelfURL to have an effect.
+func WithStrictDiscovery(strict bool) Option {
+	return func(h *Hub) {
+		h.strictDiscovery = strict
+	}
+}
+
+// Option customizes a Hub at construction time.
+type Option func(*Hub)
+
+// WithVerificationWorkers sets the number of goroutines processing the
+// subscription intent verification queue. Defaults to 4.
+func WithVerificationWorkers(n int) Option {
+	return func(h *Hub) {
+		h.verifyWorkers = n
+	}
+}
+
+const (
+	defaultLease    = time.Hour
+	defaultMinLease = 5 * time.Minute
+	defaultMaxLease = 10 * 24 * time.Hour
+)
+
+// WithLeaseBounds clamps the hub.lease_seconds a subscriber may request into
+// [min, max], per the spec's guidance that hubs MAY enforce their own
+// acceptable range. Defaults to [defaultMinLease, defaultMaxLease].
+func WithLeaseBounds(min, max time.Duration) Option {
+	return func(h *Hub) {
+		h.minLease = min
+		h.maxLease = max
+	}
+}
+
+// WithSubscriptionRepository overrides the storage backend for subscriptions.
+// Defaults to an in-memory repository; pass a *boltSubscriptionRepository (or
+// any other SubscriptionRepository) to persist subscriptions across restarts.
+func WithSubscriptionRepository(repo SubscriptionRepository) Option {
+	return func(h *Hub) {
+		h.subs = repo
+	}
 }
 
-func NewHub(logger *slog.Logger) *Hub {
+func NewHub(logger *slog.Logger, opts ...Option) *Hub {
 	mux := &http.ServeMux{}
 
 	h := &Hub{
-		logger:        logger,
-		mux:           mux,
-		subscriptions: make(map[string]*Subscription),
+		logger: logger,
+		mux:    mux,
+		subs:   NewMemorySubscriptionRepository(),
 		client: &http.Client{
 			Timeout: 5 * time.Second,
 		},
+		topics:         NewMemoryTopicRepository(),
+		verifyWorkers:  defaultVerificationWorkers,
+		verifyQueue:    make(chan *pendingVerification, 256),
+		pending:        make(map[verificationKey]*pendingVerification),
+		deliveryQueues: make(map[subscriberKey]*subscriptionQueue),
+		minLease:       defaultMinLease,
+		maxLease:       defaultMaxLease,
 	}
 
-	mux.HandleFunc("GET /a-topic", func(w http.ResponseWriter, r *http.Request) {
-		logger.Debug("a-topic")
-		w.WriteHeader(http.StatusNotImplemented)
-		w.Write([]byte("not implemented"))
-	})
+	for _, opt := range opts {
+		opt(h)
+	}
+
+	mux.HandleFunc("GET /a-topic", h.handleATopic)
 	mux.HandleFunc("POST /", h.handleSubscribe)
 	mux.HandleFunc("POST /publish", h.handlePublish)
+	mux.HandleFunc("GET /debug/pending", h.handleDebugPending)
+
+	h.startVerificationWorkers()
+	h.startLeaseReaper()
 
 	return h
 }
@@ -54,14 +131,76 @@ func (h *Hub) Mux() *http.ServeMux {
 	return h.mux
 }
 
+// handleATopic is a sample topic resource hosted by the hub itself, used to
+// demonstrate the Link headers a real topic publisher must advertise so this
+// hub can be discovered from it (see the WebSub discovery model).
+func (h *Hub) handleATopic(w http.ResponseWriter, r *http.Request) {
+	h.logger.Debug("a-topic")
+
+	self := h.selfURL + r.URL.Path
+	w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="self"`, self))
+	if h.selfURL != "" {
+		w.Header().Add("Link", fmt.Sprintf(`<%s>; rel="hub"`, h.selfURL))
+	}
+
+	w.WriteHeader(http.StatusNotImplemented)
+	w.Write([]byte("not implemented"))
+}
+
 type Topic = string
 
 type Subscription struct {
-	CallbackURL   *url.URL
-	Topic         string
+	CallbackURL *url.URL
+	Topic       string
+	secret      []byte
+
+	// mu guards LeaseDeadline, Lease and Unhealthy, which a verification
+	// worker renewing the lease (via SetLease) and the subscription's
+	// delivery worker (via markUnhealthy) can touch concurrently on the
+	// same *Subscription. Direct field access elsewhere is fine: everywhere
+	// else either constructs a fresh Subscription or reads/writes it from a
+	// single owning goroutine.
+	mu            sync.Mutex
 	LeaseDeadline time.Time
 	Lease         time.Duration
-	secret        []byte
+
+	// Delivery telemetry, mutated only by the subscription's own delivery
+	// worker goroutine.
+	DeliveryAttempts    int
+	ConsecutiveFailures int
+	NextDeliveryAttempt time.Time
+	Unhealthy           bool
+}
+
+// SetLease safely updates the subscription's lease deadline and duration, as
+// used by a repository's UpdateLease to renew an in-place *Subscription that
+// a delivery worker may be reading concurrently.
+func (s *Subscription) SetLease(deadline time.Time, lease time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.LeaseDeadline = deadline
+	s.Lease = lease
+}
+
+// LeaseDeadlineAt returns the subscription's current lease deadline.
+func (s *Subscription) LeaseDeadlineAt() time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.LeaseDeadline
+}
+
+// SetUnhealthy safely marks (or clears) the subscription as unhealthy.
+func (s *Subscription) SetUnhealthy(unhealthy bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Unhealthy = unhealthy
+}
+
+// IsUnhealthy reports whether the subscription is currently marked unhealthy.
+func (s *Subscription) IsUnhealthy() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.Unhealthy
 }
 
 func (h *Hub) handleSubscribe(w http.ResponseWriter, r *http.Request) {
@@ -82,12 +221,14 @@ func (h *Hub) handleSubscribe(w http.ResponseWriter, r *http.Request) {
 	h.logger.Info("subscription request", "callback", callback, "mode", mode, "topic", topic, "lease", lease)
 
 	if mode != ModeSubscribe && mode != ModeUnsubscribe {
+		h.metrics.observeSubscriptionRequest(mode, "rejected")
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte("invalid hub.mode value"))
 		return
 	}
 
 	if topic == "" {
+		h.metrics.observeSubscriptionRequest(mode, "rejected")
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte("missing hub.topic"))
 		return
@@ -95,17 +236,17 @@ func (h *Hub) handleSubscribe(w http.ResponseWriter, r *http.Request) {
 
 	callbackUrl, err := url.Parse(callback)
 	if err != nil {
+		h.metrics.observeSubscriptionRequest(mode, "rejected")
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte(err.Error()))
 		return
 	}
 
-	var leaseDuration time.Duration
-	if lease == "" {
-		leaseDuration = time.Hour
-	} else {
+	leaseDuration := defaultLease
+	if lease != "" {
 		leaseSeconds, err := strconv.Atoi(lease)
 		if err != nil {
+			h.metrics.observeSubscriptionRequest(mode, "rejected")
 			w.WriteHeader(http.StatusBadRequest)
 			w.Write([]byte(err.Error()))
 			return
@@ -113,6 +254,7 @@ func (h *Hub) handleSubscribe(w http.ResponseWriter, r *http.Request) {
 		// possible overflow
 		leaseDuration = time.Duration(leaseSeconds) * time.Second
 	}
+	leaseDuration = h.clampLease(leaseDuration)
 
 	subscription := Subscription{
 		CallbackURL:   callbackUrl,
@@ -122,26 +264,61 @@ func (h *Hub) handleSubscribe(w http.ResponseWriter, r *http.Request) {
 		secret:        []byte(secret),
 	}
 
-	err = h.verifySubscriptionIntent(r.Context(), &subscription, mode)
-	if err != nil {
-		h.logger.Debug(err.Error())
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("failed intent verification"))
+	h.enqueueVerification(&subscription, mode)
+	h.metrics.observeSubscriptionRequest(mode, "accepted")
+
+	h.logger.Debug(fmt.Sprintf("%+v", &subscription))
+
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(nil)
+}
+
+// commitSubscription applies a subscription whose intent has been verified,
+// either adding it (subscribe) or dropping it (unsubscribe).
+func (h *Hub) commitSubscription(sub *Subscription, mode SubscriptionMode) {
+	ctx := context.Background()
+
+	if mode == ModeUnsubscribe {
+		if err := h.subs.Remove(ctx, sub.Topic, sub.CallbackURL.String()); err != nil {
+			h.logger.Error("failed to remove subscription", "topic", sub.Topic, "callback", sub.CallbackURL.String(), "err", err)
+		}
+		h.stopDeliveryQueue(subscriberKey{Topic: sub.Topic, Callback: sub.CallbackURL.String()})
+		h.metrics.adjustActiveSubscriptions(sub.Topic, -1)
 		return
 	}
 
-	h.rwMu.Lock()
-	if mode == ModeSubscribe {
-		h.subscriptions[subscription.CallbackURL.String()] = &subscription
-	} else {
-		delete(h.subscriptions, subscription.CallbackURL.String())
+	// A subscribe request against an already-subscribed (callback, topic)
+	// pair is a renewal: extend the existing record's lease rather than
+	// replacing it, so its delivery queue and failure counters carry over.
+	existing, err := h.subs.ListByTopic(ctx, sub.Topic)
+	if err != nil {
+		h.logger.Error("failed to list subscriptions for topic", "topic", sub.Topic, "err", err)
+		return
+	}
+	for _, e := range existing {
+		if e.CallbackURL.String() == sub.CallbackURL.String() {
+			if err := h.subs.UpdateLease(ctx, sub.Topic, sub.CallbackURL.String(), sub.LeaseDeadline, sub.Lease); err != nil {
+				h.logger.Error("failed to renew subscription lease", "topic", sub.Topic, "callback", sub.CallbackURL.String(), "err", err)
+			}
+			return
+		}
 	}
-	h.rwMu.Unlock()
 
-	h.logger.Debug(fmt.Sprintf("%+v", subscription))
+	if err := h.subs.Add(ctx, sub); err != nil {
+		h.logger.Error("failed to add subscription", "topic", sub.Topic, "callback", sub.CallbackURL.String(), "err", err)
+		return
+	}
+	h.metrics.adjustActiveSubscriptions(sub.Topic, 1)
+}
 
-	w.WriteHeader(http.StatusAccepted)
-	w.Write(nil)
+func (h *Hub) clampLease(d time.Duration) time.Duration {
+	if d < h.minLease {
+		return h.minLease
+	}
+	if d > h.maxLease {
+		return h.maxLease
+	}
+	return d
 }
 
 type SubscriptionMode = string
@@ -194,51 +371,41 @@ func (h *Hub) verifySubscriptionIntent(ctx context.Context, sub *Subscription, m
 func (h *Hub) handlePublish(w http.ResponseWriter, r *http.Request) {
 	h.logger.Debug("/publish")
 
-	h.rwMu.RLock()
-	subs := make([]*Subscription, 0, len(h.subscriptions))
-	for _, s := range h.subscriptions {
-		subs = append(subs, s)
+	if err := r.ParseForm(); err != nil {
+		h.logger.Debug(err.Error())
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("error parsing body"))
+		return
 	}
-	h.rwMu.RUnlock()
 
-	message := struct {
-		Meep string `json:"meep"`
-		Mino string `json:"mino"`
-	}{
-		Meep: "meep",
-		Mino: "mino",
+	topic := r.PostFormValue("hub.url")
+	if topic == "" {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte("missing hub.url"))
+		return
 	}
-	messageData, _ := json.Marshal(message)
-
-	for _, s := range subs {
-		callbackStr := s.CallbackURL.String()
-
-		req, err := http.NewRequest("POST", s.CallbackURL.String(), bytes.NewReader(messageData))
-		if err != nil {
-			h.logger.Error(err.Error(), "callback", callbackStr, "err", err)
-			continue
-		}
 
-		if len(s.secret) > 0 {
-			mac := hmac.New(sha256.New, s.secret)
-			if _, err := mac.Write(messageData); err != nil {
-				h.logger.Error(err.Error(), "callback", callbackStr)
-			}
-			sig := "sha256=" + hex.EncodeToString(mac.Sum(nil))
-			req.Header.Add("X-Hub-Signature", sig)
-			h.logger.Debug(sig)
-		}
+	content, err := h.fetchTopic(r.Context(), topic)
+	if err != nil {
+		h.logger.Error("failed to fetch topic", "topic", topic, "err", err)
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte("failed to fetch topic"))
+		return
+	}
 
-		res, err := h.client.Do(req)
-		if err != nil {
-			h.logger.Error("failed to notify subscriber", "callback", callbackStr, "err", err)
-			continue
-		}
-		defer res.Body.Close()
+	subs, err := h.subs.ListByTopic(r.Context(), topic)
+	if err != nil {
+		h.logger.Error("failed to list subscriptions for topic", "topic", topic, "err", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		w.Write([]byte("failed to list subscriptions"))
+		return
+	}
 
-		if res.StatusCode < 200 || res.StatusCode > 299 {
-			h.logger.Error("callback returned non-2xx status", "callback", callbackStr, "status", res.Status)
-		}
+	h.metrics.observePublishFanout(len(subs))
+	for _, s := range subs {
+		h.enqueueDelivery(s, content)
 	}
 
+	w.WriteHeader(http.StatusAccepted)
+	w.Write(nil)
 }
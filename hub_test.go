@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// TestSubscribeRouting is an end-to-end regression test for the hub's
+// net/http.ServeMux routes: it drives a real request through h.Mux() over a
+// real HTTP connection, rather than calling handlers directly, so that a
+// routing mismatch (e.g. method-prefixed patterns silently not matching on
+// a go.mod declaring an older Go version) shows up as a failing test.
+func TestSubscribeRouting(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	challenges := make(chan string, 1)
+	subscriber := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		challenge := r.URL.Query().Get("hub.challenge")
+		challenges <- challenge
+		w.Write([]byte(challenge))
+	}))
+	defer subscriber.Close()
+
+	h := NewHub(logger)
+	server := httptest.NewServer(h.Mux())
+	defer server.Close()
+
+	const topic = "https://example.com/feed"
+	form := url.Values{
+		"hub.mode":     {ModeSubscribe},
+		"hub.topic":    {topic},
+		"hub.callback": {subscriber.URL},
+	}
+
+	res, err := http.PostForm(server.URL+"/", form)
+	if err != nil {
+		t.Fatalf("POST /: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusAccepted {
+		t.Fatalf("POST / status = %d, want %d", res.StatusCode, http.StatusAccepted)
+	}
+
+	select {
+	case <-challenges:
+	case <-time.After(2 * time.Second):
+		t.Fatal("subscriber never received a verification challenge")
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	var subs []*Subscription
+	for time.Now().Before(deadline) {
+		subs, err = h.subs.ListByTopic(context.Background(), topic)
+		if err != nil {
+			t.Fatalf("ListByTopic: %v", err)
+		}
+		if len(subs) == 1 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if len(subs) != 1 {
+		t.Fatalf("subscriptions for topic = %d, want 1 (verification never committed)", len(subs))
+	}
+	if subs[0].CallbackURL.String() != subscriber.URL {
+		t.Errorf("committed callback = %q, want %q", subs[0].CallbackURL.String(), subscriber.URL)
+	}
+}
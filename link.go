@@ -0,0 +1,96 @@
+package main
+
+import "strings"
+
+// Link is one parsed value from an HTTP Link header, e.g.
+// `<https://hub.example/>; rel="hub"`. net/http has no Link header parser,
+// so ParseLinkHeader implements the bits of RFC 8288 the hub needs: multiple
+// link-values per header, multiple Link headers, and quoted parameters that
+// may themselves contain commas or semicolons.
+type Link struct {
+	Target string
+	Params map[string]string
+}
+
+// Rel returns the link's "rel" parameter, or "" if it has none.
+func (l Link) Rel() string {
+	return l.Params["rel"]
+}
+
+// HasRel reports whether rel is one of the link's (possibly space-separated,
+// per RFC 8288) relation types.
+func (l Link) HasRel(rel string) bool {
+	for _, r := range strings.Fields(l.Rel()) {
+		if strings.EqualFold(r, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseLinkHeader parses the values of one or more Link headers (as returned
+// by http.Header.Values("Link")) into individual Link entries.
+func ParseLinkHeader(headers []string) []Link {
+	var links []Link
+	for _, header := range headers {
+		for _, value := range splitUnquoted(header, ',') {
+			if link, ok := parseLinkValue(strings.TrimSpace(value)); ok {
+				links = append(links, link)
+			}
+		}
+	}
+	return links
+}
+
+func parseLinkValue(s string) (Link, bool) {
+	if !strings.HasPrefix(s, "<") {
+		return Link{}, false
+	}
+	end := strings.IndexByte(s, '>')
+	if end < 0 {
+		return Link{}, false
+	}
+
+	link := Link{
+		Target: s[1:end],
+		Params: make(map[string]string),
+	}
+
+	for _, param := range splitUnquoted(s[end+1:], ';') {
+		param = strings.TrimSpace(param)
+		if param == "" {
+			continue
+		}
+
+		key, val, ok := strings.Cut(param, "=")
+		if !ok {
+			continue
+		}
+		link.Params[strings.ToLower(strings.TrimSpace(key))] = strings.Trim(strings.TrimSpace(val), `"`)
+	}
+
+	return link, true
+}
+
+// splitUnquoted splits s on sep, except where sep falls inside a
+// double-quoted span.
+func splitUnquoted(s string, sep byte) []string {
+	var parts []string
+	inQuotes := false
+	start := 0
+
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '"':
+			inQuotes = !inQuotes
+		case sep:
+			if !inQuotes {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	parts = append(parts, s[start:])
+
+	return parts
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitUnquoted(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		sep  byte
+		want []string
+	}{
+		{
+			name: "no separator",
+			s:    "hello",
+			sep:  ',',
+			want: []string{"hello"},
+		},
+		{
+			name: "simple split",
+			s:    "a,b,c",
+			sep:  ',',
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name: "separator inside quotes is preserved",
+			s:    `a,"b,c",d`,
+			sep:  ',',
+			want: []string{"a", `"b,c"`, "d"},
+		},
+		{
+			name: "different separator inside quotes is preserved",
+			s:    `<x>; rel="hub;alternate"; title="a, b"`,
+			sep:  ';',
+			want: []string{`<x>`, ` rel="hub;alternate"`, ` title="a, b"`},
+		},
+		{
+			name: "empty string",
+			s:    "",
+			sep:  ',',
+			want: []string{""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := splitUnquoted(tt.s, tt.sep)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("splitUnquoted(%q, %q) = %#v, want %#v", tt.s, tt.sep, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseLinkHeader(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers []string
+		want    []Link
+	}{
+		{
+			name:    "single link",
+			headers: []string{`<https://hub.example/>; rel="hub"`},
+			want: []Link{
+				{Target: "https://hub.example/", Params: map[string]string{"rel": "hub"}},
+			},
+		},
+		{
+			name:    "multiple link-values in one header",
+			headers: []string{`<https://hub.example/>; rel="hub", <https://example.com/feed>; rel="self"`},
+			want: []Link{
+				{Target: "https://hub.example/", Params: map[string]string{"rel": "hub"}},
+				{Target: "https://example.com/feed", Params: map[string]string{"rel": "self"}},
+			},
+		},
+		{
+			name: "multiple Link headers",
+			headers: []string{
+				`<https://hub.example/>; rel="hub"`,
+				`<https://example.com/feed>; rel="self"`,
+			},
+			want: []Link{
+				{Target: "https://hub.example/", Params: map[string]string{"rel": "hub"}},
+				{Target: "https://example.com/feed", Params: map[string]string{"rel": "self"}},
+			},
+		},
+		{
+			name:    "quoted comma in a param does not split the link-value",
+			headers: []string{`<https://example.com/feed>; rel="self"; title="a, b"`},
+			want: []Link{
+				{Target: "https://example.com/feed", Params: map[string]string{"rel": "self", "title": "a, b"}},
+			},
+		},
+		{
+			name:    "quoted semicolon in a param does not split the params",
+			headers: []string{`<https://example.com/feed>; rel="self"; title="a; b"`},
+			want: []Link{
+				{Target: "https://example.com/feed", Params: map[string]string{"rel": "self", "title": "a; b"}},
+			},
+		},
+		{
+			name:    "multiple space-separated rel values",
+			headers: []string{`<https://hub.example/>; rel="hub self"`},
+			want: []Link{
+				{Target: "https://hub.example/", Params: map[string]string{"rel": "hub self"}},
+			},
+		},
+		{
+			name:    "malformed value without closing angle bracket is skipped",
+			headers: []string{`<https://hub.example/; rel="hub"`},
+			want:    nil,
+		},
+		{
+			name:    "value without leading angle bracket is skipped",
+			headers: []string{`https://hub.example/; rel="hub"`},
+			want:    nil,
+		},
+		{
+			name:    "no headers",
+			headers: nil,
+			want:    nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseLinkHeader(tt.headers)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseLinkHeader(%v) = %#v, want %#v", tt.headers, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestLinkHasRel(t *testing.T) {
+	link := Link{Params: map[string]string{"rel": "hub self"}}
+
+	if !link.HasRel("hub") {
+		t.Error("HasRel(\"hub\") = false, want true")
+	}
+	if !link.HasRel("Self") {
+		t.Error("HasRel(\"Self\") = false, want true (case-insensitive)")
+	}
+	if link.HasRel("alternate") {
+		t.Error("HasRel(\"alternate\") = true, want false")
+	}
+}
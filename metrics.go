@@ -0,0 +1,126 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metrics holds the hub's Prometheus instrumentation. A nil *metrics (the
+// default, unless WithMetrics is passed) makes every observe/inc method a
+// no-op, so call sites never need to check whether metrics are enabled.
+type metrics struct {
+	subscriptionRequests *prometheus.CounterVec
+	verificationAttempts *prometheus.CounterVec
+	activeSubscriptions  *prometheus.GaugeVec
+	publishFanout        prometheus.Histogram
+	deliveryLatency      prometheus.Histogram
+	deliveryAttempts     *prometheus.CounterVec
+	bytesDelivered       prometheus.Counter
+}
+
+func newMetrics(reg prometheus.Registerer) *metrics {
+	m := &metrics{
+		subscriptionRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "websub_hub_subscription_requests_total",
+			Help: "Subscription requests handled, by hub.mode and outcome.",
+		}, []string{"mode", "outcome"}),
+		verificationAttempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "websub_hub_verification_attempts_total",
+			Help: "Subscription intent verification attempts, by outcome.",
+		}, []string{"outcome"}),
+		activeSubscriptions: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Name: "websub_hub_active_subscriptions",
+			Help: "Current number of active subscriptions, by topic.",
+		}, []string{"topic"}),
+		publishFanout: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "websub_hub_publish_fanout_size",
+			Help:    "Number of subscribers notified per publish.",
+			Buckets: prometheus.ExponentialBuckets(1, 2, 10),
+		}),
+		deliveryLatency: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "websub_hub_delivery_latency_seconds",
+			Help:    "Latency of a single delivery attempt to a subscriber callback.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		deliveryAttempts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "websub_hub_delivery_attempts_total",
+			Help: "Delivery attempts, by resulting HTTP status class.",
+		}, []string{"status_class"}),
+		bytesDelivered: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "websub_hub_delivery_bytes_total",
+			Help: "Total bytes of notification bodies delivered to subscribers.",
+		}),
+	}
+
+	reg.MustRegister(
+		m.subscriptionRequests,
+		m.verificationAttempts,
+		m.activeSubscriptions,
+		m.publishFanout,
+		m.deliveryLatency,
+		m.deliveryAttempts,
+		m.bytesDelivered,
+	)
+
+	return m
+}
+
+// WithMetrics opts the hub into Prometheus instrumentation, registering its
+// collectors with reg and mounting GET /metrics. Registerer is caller-
+// supplied rather than the global prometheus.DefaultRegisterer so tests can
+// use a throwaway registry.
+func WithMetrics(reg *prometheus.Registry) Option {
+	return func(h *Hub) {
+		h.metrics = newMetrics(reg)
+		h.mux.Handle("GET /metrics", promhttp.HandlerFor(reg, promhttp.HandlerOpts{}))
+	}
+}
+
+func (m *metrics) observeSubscriptionRequest(mode, outcome string) {
+	if m == nil {
+		return
+	}
+	m.subscriptionRequests.WithLabelValues(mode, outcome).Inc()
+}
+
+func (m *metrics) observeVerification(outcome string) {
+	if m == nil {
+		return
+	}
+	m.verificationAttempts.WithLabelValues(outcome).Inc()
+}
+
+func (m *metrics) adjustActiveSubscriptions(topic string, delta float64) {
+	if m == nil {
+		return
+	}
+	m.activeSubscriptions.WithLabelValues(topic).Add(delta)
+}
+
+func (m *metrics) observePublishFanout(n int) {
+	if m == nil {
+		return
+	}
+	m.publishFanout.Observe(float64(n))
+}
+
+func (m *metrics) observeDelivery(status int, latency time.Duration, bodyBytes int) {
+	if m == nil {
+		return
+	}
+	m.deliveryLatency.Observe(latency.Seconds())
+	m.deliveryAttempts.WithLabelValues(statusClass(status)).Inc()
+	if status >= 200 && status <= 299 {
+		m.bytesDelivered.Add(float64(bodyBytes))
+	}
+}
+
+func statusClass(status int) string {
+	if status == 0 {
+		return "transport_error"
+	}
+	return strconv.Itoa(status/100) + "xx"
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func TestNewMetricsRegistersCollectors(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	m := newMetrics(reg)
+
+	m.observeSubscriptionRequest(ModeSubscribe, "accepted")
+	m.observeVerification("success")
+	m.adjustActiveSubscriptions("https://example.com/feed", 1)
+	m.observePublishFanout(3)
+	m.observeDelivery(http.StatusOK, 10*time.Millisecond, 128)
+
+	families, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("Gather: %v", err)
+	}
+
+	names := make(map[string]bool, len(families))
+	for _, f := range families {
+		names[f.GetName()] = true
+	}
+
+	for _, want := range []string{
+		"websub_hub_subscription_requests_total",
+		"websub_hub_verification_attempts_total",
+		"websub_hub_active_subscriptions",
+		"websub_hub_publish_fanout_size",
+		"websub_hub_delivery_latency_seconds",
+		"websub_hub_delivery_attempts_total",
+		"websub_hub_delivery_bytes_total",
+	} {
+		if !names[want] {
+			t.Errorf("metric %q not registered", want)
+		}
+	}
+}
+
+func TestWithMetricsMountsEndpoint(t *testing.T) {
+	reg := prometheus.NewRegistry()
+	h := NewHub(slog.New(slog.NewTextHandler(io.Discard, nil)), WithMetrics(reg))
+
+	h.metrics.observeSubscriptionRequest(ModeSubscribe, "accepted")
+
+	server := httptest.NewServer(h.Mux())
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatalf("GET /metrics: %v", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		t.Fatalf("GET /metrics status = %d, want %d", res.StatusCode, http.StatusOK)
+	}
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		t.Fatalf("reading /metrics body: %v", err)
+	}
+
+	if !strings.Contains(string(body), "websub_hub_subscription_requests_total") {
+		t.Error("/metrics output missing websub_hub_subscription_requests_total")
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+const defaultLeaseReapInterval = time.Minute
+
+// WithLeaseReapInterval sets how often the reaper scans for expired
+// subscriptions. Defaults to defaultLeaseReapInterval.
+func WithLeaseReapInterval(d time.Duration) Option {
+	return func(h *Hub) {
+		h.leaseReapInterval = d
+	}
+}
+
+// WithExpiryHook registers a callback invoked whenever a subscription is
+// reaped for lease expiry, in addition to the structured log line.
+func WithExpiryHook(fn func(*Subscription)) Option {
+	return func(h *Hub) {
+		h.onExpiry = fn
+	}
+}
+
+// startLeaseReaper launches the background goroutine that periodically asks
+// the subscription repository for expired leases and removes them. A
+// subscriber that wants to keep receiving notifications must renew before
+// its lease runs out (see commitSubscription's renewal handling).
+func (h *Hub) startLeaseReaper() {
+	interval := h.leaseReapInterval
+	if interval <= 0 {
+		interval = defaultLeaseReapInterval
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			h.reapExpiredSubscriptions()
+		}
+	}()
+}
+
+func (h *Hub) reapExpiredSubscriptions() {
+	ctx := context.Background()
+
+	expired, err := h.subs.ListExpiringBefore(ctx, time.Now())
+	if err != nil {
+		h.logger.Error("failed to list expiring subscriptions", "err", err)
+		return
+	}
+
+	for _, sub := range expired {
+		callback := sub.CallbackURL.String()
+
+		if err := h.subs.Remove(ctx, sub.Topic, callback); err != nil {
+			h.logger.Error("failed to remove expired subscription", "topic", sub.Topic, "callback", callback, "err", err)
+			continue
+		}
+
+		h.logger.Info("subscription.expired", "callback", callback, "topic", sub.Topic)
+		h.stopDeliveryQueue(subscriberKey{Topic: sub.Topic, Callback: callback})
+		h.metrics.adjustActiveSubscriptions(sub.Topic, -1)
+		if h.onExpiry != nil {
+			h.onExpiry(sub)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+)
+
+func TestReapExpiredSubscriptions(t *testing.T) {
+	var expired *Subscription
+	h := NewHub(
+		slog.New(slog.NewTextHandler(io.Discard, nil)),
+		WithExpiryHook(func(sub *Subscription) { expired = sub }),
+	)
+
+	live := newTestSubscription(t, "http://live.example/callback", "https://example.com/feed")
+	live.LeaseDeadline = time.Now().Add(time.Hour)
+	if err := h.subs.Add(context.Background(), live); err != nil {
+		t.Fatalf("Add(live): %v", err)
+	}
+
+	dead := newTestSubscription(t, "http://dead.example/callback", "https://example.com/feed")
+	dead.LeaseDeadline = time.Now().Add(-time.Minute)
+	if err := h.subs.Add(context.Background(), dead); err != nil {
+		t.Fatalf("Add(dead): %v", err)
+	}
+
+	h.reapExpiredSubscriptions()
+
+	remaining, err := h.subs.ListByTopic(context.Background(), "https://example.com/feed")
+	if err != nil {
+		t.Fatalf("ListByTopic: %v", err)
+	}
+	if len(remaining) != 1 || remaining[0].CallbackURL.String() != live.CallbackURL.String() {
+		t.Fatalf("remaining subscriptions = %v, want only %v", remaining, live.CallbackURL)
+	}
+
+	if expired == nil || expired.CallbackURL.String() != dead.CallbackURL.String() {
+		t.Errorf("WithExpiryHook callback = %v, want %v", expired, dead.CallbackURL)
+	}
+}
+
+func TestRenewalExtendsLeaseAndSurvivesReap(t *testing.T) {
+	h := NewHub(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	sub := newTestSubscription(t, "http://renew.example/callback", "https://example.com/feed")
+	sub.LeaseDeadline = time.Now().Add(-time.Minute)
+	if err := h.subs.Add(context.Background(), sub); err != nil {
+		t.Fatalf("Add: %v", err)
+	}
+
+	h.commitSubscription(&Subscription{
+		CallbackURL:   sub.CallbackURL,
+		Topic:         sub.Topic,
+		LeaseDeadline: time.Now().Add(time.Hour),
+		Lease:         time.Hour,
+	}, ModeSubscribe)
+
+	h.reapExpiredSubscriptions()
+
+	remaining, err := h.subs.ListByTopic(context.Background(), sub.Topic)
+	if err != nil {
+		t.Fatalf("ListByTopic: %v", err)
+	}
+	if len(remaining) != 1 {
+		t.Fatalf("renewed subscription was reaped: remaining = %v", remaining)
+	}
+}
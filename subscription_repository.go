@@ -0,0 +1,135 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SubscriptionRepository stores subscriptions so that Hub doesn't need to
+// know whether they live in memory or on disk. The memory implementation
+// below preserves the hub's original in-process behavior; a BoltDB or SQLite
+// backed implementation can satisfy the same interface to survive restarts.
+type SubscriptionRepository interface {
+	Add(ctx context.Context, sub *Subscription) error
+	Remove(ctx context.Context, topic, callback string) error
+	GetByCallback(ctx context.Context, callback string) ([]*Subscription, error)
+	ListByTopic(ctx context.Context, topic string) ([]*Subscription, error)
+	ListExpiringBefore(ctx context.Context, t time.Time) ([]*Subscription, error)
+	UpdateLease(ctx context.Context, topic, callback string, deadline time.Time, lease time.Duration) error
+	UpdateDeliveryState(ctx context.Context, topic, callback string, attempts, consecutiveFailures int, unhealthy bool) error
+}
+
+type memorySubscriptionRepository struct {
+	mu     sync.RWMutex
+	topics map[string]map[string]*Subscription // topic -> callback -> sub
+}
+
+func NewMemorySubscriptionRepository() *memorySubscriptionRepository {
+	return &memorySubscriptionRepository{
+		topics: make(map[string]map[string]*Subscription),
+	}
+}
+
+func (r *memorySubscriptionRepository) Add(_ context.Context, sub *Subscription) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	byCallback, ok := r.topics[sub.Topic]
+	if !ok {
+		byCallback = make(map[string]*Subscription)
+		r.topics[sub.Topic] = byCallback
+	}
+	byCallback[sub.CallbackURL.String()] = sub
+	return nil
+}
+
+func (r *memorySubscriptionRepository) Remove(_ context.Context, topic, callback string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	byCallback, ok := r.topics[topic]
+	if !ok {
+		return nil
+	}
+	delete(byCallback, callback)
+	if len(byCallback) == 0 {
+		delete(r.topics, topic)
+	}
+	return nil
+}
+
+func (r *memorySubscriptionRepository) GetByCallback(_ context.Context, callback string) ([]*Subscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var subs []*Subscription
+	for _, byCallback := range r.topics {
+		if sub, ok := byCallback[callback]; ok {
+			subs = append(subs, sub)
+		}
+	}
+	return subs, nil
+}
+
+func (r *memorySubscriptionRepository) ListByTopic(_ context.Context, topic string) ([]*Subscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	byCallback := r.topics[topic]
+	subs := make([]*Subscription, 0, len(byCallback))
+	for _, sub := range byCallback {
+		subs = append(subs, sub)
+	}
+	return subs, nil
+}
+
+func (r *memorySubscriptionRepository) ListExpiringBefore(_ context.Context, t time.Time) ([]*Subscription, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var subs []*Subscription
+	for _, byCallback := range r.topics {
+		for _, sub := range byCallback {
+			if sub.LeaseDeadlineAt().Before(t) {
+				subs = append(subs, sub)
+			}
+		}
+	}
+	return subs, nil
+}
+
+func (r *memorySubscriptionRepository) UpdateLease(_ context.Context, topic, callback string, deadline time.Time, lease time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	byCallback, ok := r.topics[topic]
+	if !ok {
+		return fmt.Errorf("subscription repository: no subscriptions for topic %q", topic)
+	}
+	sub, ok := byCallback[callback]
+	if !ok {
+		return fmt.Errorf("subscription repository: no subscription for callback %q on topic %q", callback, topic)
+	}
+	sub.SetLease(deadline, lease)
+	return nil
+}
+
+func (r *memorySubscriptionRepository) UpdateDeliveryState(_ context.Context, topic, callback string, attempts, consecutiveFailures int, unhealthy bool) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	byCallback, ok := r.topics[topic]
+	if !ok {
+		return fmt.Errorf("subscription repository: no subscriptions for topic %q", topic)
+	}
+	sub, ok := byCallback[callback]
+	if !ok {
+		return fmt.Errorf("subscription repository: no subscription for callback %q on topic %q", callback, topic)
+	}
+	sub.DeliveryAttempts = attempts
+	sub.ConsecutiveFailures = consecutiveFailures
+	sub.SetUnhealthy(unhealthy)
+	return nil
+}
@@ -0,0 +1,233 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+
+	"go.etcd.io/bbolt"
+)
+
+var subscriptionsBucket = []byte("subscriptions")
+
+// boltSubscriptionRepository persists subscriptions to a BoltDB file so a
+// hub restart doesn't drop every subscriber. Each record is keyed by
+// "<topic>\x00<callback>" and JSON-encoded, which keeps ListByTopic a cheap
+// prefix scan over the bucket's sorted keys.
+type boltSubscriptionRepository struct {
+	db *bbolt.DB
+}
+
+// NewBoltSubscriptionRepository opens (creating if necessary) a BoltDB file
+// at path and returns a repository backed by it.
+func NewBoltSubscriptionRepository(path string) (*boltSubscriptionRepository, error) {
+	db, err := bbolt.Open(path, 0600, &bbolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening bolt db: %w", err)
+	}
+
+	err = db.Update(func(tx *bbolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(subscriptionsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("creating subscriptions bucket: %w", err)
+	}
+
+	return &boltSubscriptionRepository{db: db}, nil
+}
+
+func (r *boltSubscriptionRepository) Close() error {
+	return r.db.Close()
+}
+
+// boltSubscriptionRecord is the JSON wire format stored in BoltDB. Subscription
+// itself isn't used directly because its secret and callback URL need
+// explicit (de)serialization.
+type boltSubscriptionRecord struct {
+	CallbackURL         string    `json:"callback_url"`
+	Topic               string    `json:"topic"`
+	LeaseDeadline       time.Time `json:"lease_deadline"`
+	LeaseSeconds        float64   `json:"lease_seconds"`
+	Secret              []byte    `json:"secret,omitempty"`
+	DeliveryAttempts    int       `json:"delivery_attempts"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	Unhealthy           bool      `json:"unhealthy"`
+}
+
+func subscriptionKey(topic, callback string) []byte {
+	return []byte(topic + "\x00" + callback)
+}
+
+func toBoltRecord(sub *Subscription) *boltSubscriptionRecord {
+	return &boltSubscriptionRecord{
+		CallbackURL:         sub.CallbackURL.String(),
+		Topic:               sub.Topic,
+		LeaseDeadline:       sub.LeaseDeadline,
+		LeaseSeconds:        sub.Lease.Seconds(),
+		Secret:              sub.secret,
+		DeliveryAttempts:    sub.DeliveryAttempts,
+		ConsecutiveFailures: sub.ConsecutiveFailures,
+		Unhealthy:           sub.Unhealthy,
+	}
+}
+
+func fromBoltRecord(rec *boltSubscriptionRecord) (*Subscription, error) {
+	callbackURL, err := url.Parse(rec.CallbackURL)
+	if err != nil {
+		return nil, fmt.Errorf("parsing stored callback url: %w", err)
+	}
+
+	return &Subscription{
+		CallbackURL:         callbackURL,
+		Topic:               rec.Topic,
+		LeaseDeadline:       rec.LeaseDeadline,
+		Lease:               time.Duration(rec.LeaseSeconds * float64(time.Second)),
+		secret:              rec.Secret,
+		DeliveryAttempts:    rec.DeliveryAttempts,
+		ConsecutiveFailures: rec.ConsecutiveFailures,
+		Unhealthy:           rec.Unhealthy,
+	}, nil
+}
+
+func (r *boltSubscriptionRepository) Add(_ context.Context, sub *Subscription) error {
+	data, err := json.Marshal(toBoltRecord(sub))
+	if err != nil {
+		return err
+	}
+
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(subscriptionsBucket).Put(subscriptionKey(sub.Topic, sub.CallbackURL.String()), data)
+	})
+}
+
+func (r *boltSubscriptionRepository) Remove(_ context.Context, topic, callback string) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(subscriptionsBucket).Delete(subscriptionKey(topic, callback))
+	})
+}
+
+func (r *boltSubscriptionRepository) GetByCallback(_ context.Context, callback string) ([]*Subscription, error) {
+	var subs []*Subscription
+
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(subscriptionsBucket).ForEach(func(_, v []byte) error {
+			var rec boltSubscriptionRecord
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+			if rec.CallbackURL != callback {
+				return nil
+			}
+			sub, err := fromBoltRecord(&rec)
+			if err != nil {
+				return err
+			}
+			subs = append(subs, sub)
+			return nil
+		})
+	})
+
+	return subs, err
+}
+
+func (r *boltSubscriptionRepository) ListByTopic(_ context.Context, topic string) ([]*Subscription, error) {
+	var subs []*Subscription
+
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		c := tx.Bucket(subscriptionsBucket).Cursor()
+		prefix := []byte(topic + "\x00")
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var rec boltSubscriptionRecord
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+			sub, err := fromBoltRecord(&rec)
+			if err != nil {
+				return err
+			}
+			subs = append(subs, sub)
+		}
+		return nil
+	})
+
+	return subs, err
+}
+
+func (r *boltSubscriptionRepository) ListExpiringBefore(_ context.Context, t time.Time) ([]*Subscription, error) {
+	var subs []*Subscription
+
+	err := r.db.View(func(tx *bbolt.Tx) error {
+		return tx.Bucket(subscriptionsBucket).ForEach(func(_, v []byte) error {
+			var rec boltSubscriptionRecord
+			if err := json.Unmarshal(v, &rec); err != nil {
+				return err
+			}
+			if rec.LeaseDeadline.Before(t) {
+				sub, err := fromBoltRecord(&rec)
+				if err != nil {
+					return err
+				}
+				subs = append(subs, sub)
+			}
+			return nil
+		})
+	})
+
+	return subs, err
+}
+
+func (r *boltSubscriptionRepository) UpdateLease(ctx context.Context, topic, callback string, deadline time.Time, lease time.Duration) error {
+	key := subscriptionKey(topic, callback)
+
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(subscriptionsBucket)
+		data := b.Get(key)
+		if data == nil {
+			return fmt.Errorf("subscription repository: no subscription for callback %q on topic %q", callback, topic)
+		}
+
+		var rec boltSubscriptionRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return err
+		}
+		rec.LeaseDeadline = deadline
+		rec.LeaseSeconds = lease.Seconds()
+
+		updated, err := json.Marshal(&rec)
+		if err != nil {
+			return err
+		}
+		return b.Put(key, updated)
+	})
+}
+
+func (r *boltSubscriptionRepository) UpdateDeliveryState(_ context.Context, topic, callback string, attempts, consecutiveFailures int, unhealthy bool) error {
+	key := subscriptionKey(topic, callback)
+
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(subscriptionsBucket)
+		data := b.Get(key)
+		if data == nil {
+			return fmt.Errorf("subscription repository: no subscription for callback %q on topic %q", callback, topic)
+		}
+
+		var rec boltSubscriptionRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return err
+		}
+		rec.DeliveryAttempts = attempts
+		rec.ConsecutiveFailures = consecutiveFailures
+		rec.Unhealthy = unhealthy
+
+		updated, err := json.Marshal(&rec)
+		if err != nil {
+			return err
+		}
+		return b.Put(key, updated)
+	})
+}
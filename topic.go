@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TopicContent is the last-known representation of a topic URL, as observed
+// the last time the hub fetched it for distribution.
+type TopicContent struct {
+	URL           string
+	ContentType   string
+	ETag          string
+	BodyHash      string
+	Body          []byte
+	FetchedAt     time.Time
+	AdvertisesHub bool
+}
+
+// TopicRepository stores what the hub knows about topic URLs. The memory
+// implementation below is enough to get distribution working; a SQL or
+// BoltDB backed implementation can satisfy the same interface later without
+// touching Hub.
+type TopicRepository interface {
+	Get(ctx context.Context, topic Topic) (*TopicContent, bool, error)
+	Put(ctx context.Context, content *TopicContent) error
+}
+
+type memoryTopicRepository struct {
+	mu     sync.RWMutex
+	topics map[Topic]*TopicContent
+}
+
+func NewMemoryTopicRepository() *memoryTopicRepository {
+	return &memoryTopicRepository{
+		topics: make(map[Topic]*TopicContent),
+	}
+}
+
+func (r *memoryTopicRepository) Get(_ context.Context, topic Topic) (*TopicContent, bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	content, ok := r.topics[topic]
+	return content, ok, nil
+}
+
+func (r *memoryTopicRepository) Put(_ context.Context, content *TopicContent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.topics[content.URL] = content
+	return nil
+}
+
+// fetchTopic GETs the topic URL and records the resulting content in the
+// topic repository, returning the fetched content so callers can fan it out
+// without a second read from the repository.
+func (h *Hub) fetchTopic(ctx context.Context, topic Topic) (*TopicContent, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, topic, nil)
+	if err != nil {
+		return nil, fmt.Errorf("building topic request: %w", err)
+	}
+
+	res, err := h.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching topic: %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading topic body: %w", err)
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, fmt.Errorf("topic fetch returned %s", res.Status)
+	}
+
+	advertisesHub := h.selfURL == ""
+	for _, link := range ParseLinkHeader(res.Header.Values("Link")) {
+		if link.HasRel("hub") && link.Target == h.selfURL {
+			advertisesHub = true
+			break
+		}
+	}
+
+	if h.strictDiscovery && !advertisesHub {
+		return nil, fmt.Errorf("topic %s does not advertise this hub via rel=\"hub\" Link header", topic)
+	}
+
+	sum := sha256.Sum256(body)
+	content := &TopicContent{
+		URL:           topic,
+		ContentType:   res.Header.Get("Content-Type"),
+		ETag:          res.Header.Get("ETag"),
+		BodyHash:      hex.EncodeToString(sum[:]),
+		Body:          body,
+		FetchedAt:     time.Now(),
+		AdvertisesHub: advertisesHub,
+	}
+
+	if err := h.topics.Put(ctx, content); err != nil {
+		return nil, fmt.Errorf("storing topic content: %w", err)
+	}
+
+	return content, nil
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetchTopicStoresContent(t *testing.T) {
+	origin := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"abc123"`)
+		w.Write([]byte(`{"hello":"world"}`))
+	}))
+	defer origin.Close()
+
+	h := NewHub(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	content, err := h.fetchTopic(context.Background(), origin.URL)
+	if err != nil {
+		t.Fatalf("fetchTopic: %v", err)
+	}
+
+	if content.ContentType != "application/json" {
+		t.Errorf("ContentType = %q, want application/json", content.ContentType)
+	}
+	if content.ETag != `"abc123"` {
+		t.Errorf("ETag = %q, want \"abc123\"", content.ETag)
+	}
+	if string(content.Body) != `{"hello":"world"}` {
+		t.Errorf("Body = %q, want {\"hello\":\"world\"}", content.Body)
+	}
+
+	stored, ok, err := h.topics.Get(context.Background(), origin.URL)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !ok {
+		t.Fatal("fetchTopic did not persist content to the topic repository")
+	}
+	if stored.BodyHash != content.BodyHash {
+		t.Errorf("stored BodyHash = %q, want %q", stored.BodyHash, content.BodyHash)
+	}
+}
+
+func TestFetchTopicStrictDiscovery(t *testing.T) {
+	const selfURL = "https://hub.example/"
+
+	advertising := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Link", `<`+selfURL+`>; rel="hub"`)
+		w.Write([]byte("ok"))
+	}))
+	defer advertising.Close()
+
+	silent := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer silent.Close()
+
+	h := NewHub(slog.New(slog.NewTextHandler(io.Discard, nil)), WithSelfURL(selfURL), WithStrictDiscovery(true))
+
+	if _, err := h.fetchTopic(context.Background(), advertising.URL); err != nil {
+		t.Errorf("fetchTopic(advertising topic) = %v, want nil error", err)
+	}
+
+	if _, err := h.fetchTopic(context.Background(), silent.URL); err == nil {
+		t.Error("fetchTopic(non-advertising topic) = nil error, want rejection under strict discovery")
+	}
+}
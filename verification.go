@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+const defaultVerificationWorkers = 4
+
+// verificationBackoff is the retry schedule for failed intent verifications,
+// per the WebSub spec's guidance to retry with exponential backoff. The last
+// entry repeats until verificationGiveUp has elapsed since the first attempt.
+var verificationBackoff = []time.Duration{
+	30 * time.Second,
+	2 * time.Minute,
+	10 * time.Minute,
+	time.Hour,
+}
+
+const verificationGiveUp = 24 * time.Hour
+
+// verificationScanInterval is how often the scheduler checks the pending
+// queue for jobs whose nextAttempt has come due.
+const verificationScanInterval = time.Second
+
+type verificationKey struct {
+	Callback string
+	Topic    string
+	Mode     SubscriptionMode
+}
+
+// pendingVerification is a subscription intent verification that has not yet
+// succeeded, sitting in the queue until its nextAttempt time arrives.
+type pendingVerification struct {
+	key          verificationKey
+	sub          *Subscription
+	mode         SubscriptionMode
+	attempt      int
+	firstAttempt time.Time
+	nextAttempt  time.Time
+	lastError    string
+}
+
+// enqueueVerification adds a subscription intent to the pending queue.
+// handleSubscribe calls this and returns immediately; a worker performs the
+// actual callback round-trip asynchronously.
+func (h *Hub) enqueueVerification(sub *Subscription, mode SubscriptionMode) {
+	key := verificationKey{
+		Callback: sub.CallbackURL.String(),
+		Topic:    sub.Topic,
+		Mode:     mode,
+	}
+
+	now := time.Now()
+	pv := &pendingVerification{
+		key:          key,
+		sub:          sub,
+		mode:         mode,
+		firstAttempt: now,
+		nextAttempt:  now,
+	}
+
+	h.pendingMu.Lock()
+	h.pending[key] = pv
+	h.pendingMu.Unlock()
+}
+
+// startVerificationWorkers launches the scheduler goroutine and the pool of
+// workers that perform the actual GET-with-challenge round trip.
+func (h *Hub) startVerificationWorkers() {
+	workers := h.verifyWorkers
+	if workers <= 0 {
+		workers = defaultVerificationWorkers
+	}
+
+	for i := 0; i < workers; i++ {
+		go h.verificationWorker()
+	}
+
+	go h.verificationScheduler()
+}
+
+// verificationScheduler periodically scans the pending map for jobs whose
+// nextAttempt has arrived and hands them to a worker. It never blocks: a job
+// that can't be enqueued this tick (queue full) is picked up on the next one.
+func (h *Hub) verificationScheduler() {
+	ticker := time.NewTicker(verificationScanInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		now := time.Now()
+
+		// Due jobs are removed from h.pending here, before being handed to a
+		// worker, so they can't be matched and dispatched a second time while
+		// their callback round trip is still in flight.
+		h.pendingMu.Lock()
+		due := make([]*pendingVerification, 0)
+		for key, pv := range h.pending {
+			if !pv.nextAttempt.After(now) {
+				due = append(due, pv)
+				delete(h.pending, key)
+			}
+		}
+		h.pendingMu.Unlock()
+
+		for _, pv := range due {
+			select {
+			case h.verifyQueue <- pv:
+			default:
+				// Queue is full; put it back so the next tick retries it.
+				h.pendingMu.Lock()
+				h.pending[pv.key] = pv
+				h.pendingMu.Unlock()
+			}
+		}
+	}
+}
+
+func (h *Hub) verificationWorker() {
+	for pv := range h.verifyQueue {
+		h.processVerification(pv)
+	}
+}
+
+func (h *Hub) processVerification(pv *pendingVerification) {
+	pv.attempt++
+
+	ctx := context.Background()
+
+	if pv.mode == ModeSubscribe && h.strictDiscovery {
+		if _, err := h.fetchTopic(ctx, pv.sub.Topic); err != nil {
+			h.logger.Info("rejecting subscription, topic does not advertise this hub", "callback", pv.key.Callback, "topic", pv.key.Topic, "err", err)
+			h.metrics.observeVerification("rejected")
+			h.finishPending(pv)
+			return
+		}
+	}
+
+	err := h.verifySubscriptionIntent(ctx, pv.sub, pv.mode)
+	if err == nil {
+		h.commitSubscription(pv.sub, pv.mode)
+		h.metrics.observeVerification("success")
+		h.finishPending(pv)
+		return
+	}
+
+	pv.lastError = err.Error()
+	h.logger.Debug("verification attempt failed", "callback", pv.key.Callback, "topic", pv.key.Topic, "attempt", pv.attempt, "err", err)
+
+	if time.Since(pv.firstAttempt) > verificationGiveUp {
+		h.logger.Info("giving up on subscription intent verification", "callback", pv.key.Callback, "topic", pv.key.Topic, "mode", pv.mode)
+		h.metrics.observeVerification("gave_up")
+		h.finishPending(pv)
+		return
+	}
+
+	h.metrics.observeVerification("retry")
+
+	pv.nextAttempt = time.Now().Add(backoffForAttempt(pv.attempt))
+	h.reschedulePending(pv)
+}
+
+// finishPending removes pv from the pending queue now that it has reached a
+// terminal outcome (success, rejected, or gave up) — but only if pv is still
+// the job registered under its key. While pv's callback round trip was in
+// flight, a newer subscribe or unsubscribe request for the same
+// (callback, topic, mode) may have enqueued a different job under the same
+// key; that job must not be silently dropped out from under it.
+func (h *Hub) finishPending(pv *pendingVerification) {
+	h.pendingMu.Lock()
+	defer h.pendingMu.Unlock()
+	if cur, ok := h.pending[pv.key]; !ok || cur == pv {
+		delete(h.pending, pv.key)
+	}
+}
+
+// reschedulePending re-queues pv for its next retry attempt, unless a newer
+// enqueueVerification call has already superseded it under the same key.
+func (h *Hub) reschedulePending(pv *pendingVerification) {
+	h.pendingMu.Lock()
+	defer h.pendingMu.Unlock()
+	if cur, ok := h.pending[pv.key]; !ok || cur == pv {
+		h.pending[pv.key] = pv
+	}
+}
+
+func backoffForAttempt(attempt int) time.Duration {
+	i := attempt - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(verificationBackoff) {
+		i = len(verificationBackoff) - 1
+	}
+	return verificationBackoff[i]
+}
+
+// handleDebugPending exposes the pending verification queue for operators.
+func (h *Hub) handleDebugPending(w http.ResponseWriter, r *http.Request) {
+	type entry struct {
+		Callback    string    `json:"callback"`
+		Topic       string    `json:"topic"`
+		Mode        string    `json:"mode"`
+		Attempt     int       `json:"attempt"`
+		NextAttempt time.Time `json:"next_attempt"`
+		LastError   string    `json:"last_error,omitempty"`
+	}
+
+	h.pendingMu.Lock()
+	entries := make([]entry, 0, len(h.pending))
+	for _, pv := range h.pending {
+		entries = append(entries, entry{
+			Callback:    pv.key.Callback,
+			Topic:       pv.key.Topic,
+			Mode:        pv.mode,
+			Attempt:     pv.attempt,
+			NextAttempt: pv.nextAttempt,
+			LastError:   pv.lastError,
+		})
+	}
+	h.pendingMu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(entries)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+)
+
+// TestFinishPendingDoesNotClobberSupersedingJob guards against the race where
+// a second subscribe/unsubscribe request for the same (callback, topic,
+// mode) is enqueued while an earlier attempt for that same key is still in
+// flight: the earlier attempt's completion must not delete or overwrite the
+// newer job it was superseded by.
+func TestFinishPendingDoesNotClobberSupersedingJob(t *testing.T) {
+	h := NewHub(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	key := verificationKey{Callback: "http://example.com/callback", Topic: "http://example.com/feed", Mode: ModeSubscribe}
+	stale := &pendingVerification{key: key}
+	fresh := &pendingVerification{key: key}
+
+	// Simulate: stale was dispatched to a worker (removed from h.pending by
+	// the scheduler), and while its callback round trip was in flight, a
+	// second subscribe request enqueued fresh under the same key.
+	h.pendingMu.Lock()
+	h.pending[key] = fresh
+	h.pendingMu.Unlock()
+
+	h.finishPending(stale)
+
+	h.pendingMu.Lock()
+	got, ok := h.pending[key]
+	h.pendingMu.Unlock()
+
+	if !ok || got != fresh {
+		t.Fatalf("finishPending(stale) clobbered the superseding job: got %v, ok=%v, want %v", got, ok, fresh)
+	}
+}
+
+// TestFinishPendingRemovesCurrentJob is the non-colliding case: when pv is
+// still (or once again) the job registered under its key, finishPending
+// removes it as usual.
+func TestFinishPendingRemovesCurrentJob(t *testing.T) {
+	h := NewHub(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	key := verificationKey{Callback: "http://example.com/callback", Topic: "http://example.com/feed", Mode: ModeSubscribe}
+	pv := &pendingVerification{key: key}
+
+	h.pendingMu.Lock()
+	h.pending[key] = pv
+	h.pendingMu.Unlock()
+
+	h.finishPending(pv)
+
+	h.pendingMu.Lock()
+	_, ok := h.pending[key]
+	h.pendingMu.Unlock()
+
+	if ok {
+		t.Fatal("finishPending(pv) left pv in h.pending")
+	}
+}
+
+// TestReschedulePendingDoesNotClobberSupersedingJob mirrors
+// TestFinishPendingDoesNotClobberSupersedingJob for the retry path: a stale
+// attempt's backoff reschedule must not overwrite a job that superseded it.
+func TestReschedulePendingDoesNotClobberSupersedingJob(t *testing.T) {
+	h := NewHub(slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	key := verificationKey{Callback: "http://example.com/callback", Topic: "http://example.com/feed", Mode: ModeSubscribe}
+	stale := &pendingVerification{key: key}
+	fresh := &pendingVerification{key: key}
+
+	h.pendingMu.Lock()
+	h.pending[key] = fresh
+	h.pendingMu.Unlock()
+
+	h.reschedulePending(stale)
+
+	h.pendingMu.Lock()
+	got, ok := h.pending[key]
+	h.pendingMu.Unlock()
+
+	if !ok || got != fresh {
+		t.Fatalf("reschedulePending(stale) clobbered the superseding job: got %v, ok=%v, want %v", got, ok, fresh)
+	}
+}